@@ -0,0 +1,297 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+var (
+	addr         = flag.String("addr", "127.0.0.1:3330", "gRPC server address")
+	certFile     = flag.String("cert", "", "path to client certificate (for mTLS)")
+	keyFile      = flag.String("certkey", "", "path to client certificate key (for mTLS)")
+	caFile       = flag.String("cacert", "", "path to CA bundle used to verify the server certificate")
+	insecureConn = flag.Bool("insecure", false, "dial over plaintext instead of TLS")
+)
+
+// operations maps the CLI's friendly subcommands onto fully-qualified
+// "service/Method" RPCs, resolved against whatever the target master
+// actually exposes via server reflection. Any other "service/Method" can be
+// passed directly, so the CLI isn't limited to these.
+var operations = map[string]string{
+	"workers": "abstruse.WorkerService/ListWorkers",
+	"build":   "abstruse.BuildService/TriggerBuild",
+	"logs":    "abstruse.BuildService/TailLogs",
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	method, ok := operations[args[0]]
+	if !ok {
+		method = args[0]
+	}
+
+	payload := "{}"
+	if len(args) > 1 {
+		payload = strings.Join(args[1:], " ")
+	}
+
+	conn, err := dial(*addr)
+	if err != nil {
+		log.Fatalf("abstruse-cli: dial: %v", err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := call(ctx, conn, method, payload); err != nil {
+		log.Fatalf("abstruse-cli: %v", err)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: abstruse-cli [flags] <workers|build|logs|Service/Method> [json request]\n\n")
+	flag.PrintDefaults()
+}
+
+// dial connects to addr using the same TLS material conventions as the
+// master server's gRPC listener, so operators can point abstruse-cli at a
+// worker-authenticated endpoint with the same cert/key pair.
+func dial(addr string) (*grpc.ClientConn, error) {
+	if *insecureConn {
+		return grpc.Dial(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	}
+
+	tlsConfig := &tls.Config{}
+
+	if *certFile != "" && *keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(*certFile, *keyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if *caFile != "" {
+		pem, err := ioutil.ReadFile(*caFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("abstruse-cli: failed to parse CA file %s", *caFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return grpc.Dial(addr, grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)))
+}
+
+// call resolves method ("Service/Method") via the server's reflection
+// service, builds a request message from payload without any generated
+// proto code, invokes the RPC (unary or server-streaming) and prints each
+// response message as JSON.
+func call(ctx context.Context, conn *grpc.ClientConn, method, payload string) error {
+	svc, mth, err := splitMethod(method)
+	if err != nil {
+		return err
+	}
+
+	files, err := resolveFiles(ctx, conn, svc)
+	if err != nil {
+		return fmt.Errorf("reflection: %w", err)
+	}
+
+	svcDesc, err := findService(files, svc)
+	if err != nil {
+		return err
+	}
+
+	mthDesc := svcDesc.Methods().ByName(protoreflect.Name(mth))
+	if mthDesc == nil {
+		return fmt.Errorf("method %s not found on service %s", mth, svc)
+	}
+
+	req := dynamicpb.NewMessage(mthDesc.Input())
+	if err := protojson.Unmarshal([]byte(payload), req); err != nil {
+		return fmt.Errorf("decoding request: %w", err)
+	}
+
+	fullMethod := fmt.Sprintf("/%s/%s", svc, mth)
+
+	if mthDesc.IsStreamingServer() {
+		return callServerStream(ctx, conn, fullMethod, req, mthDesc.Output())
+	}
+
+	resp := dynamicpb.NewMessage(mthDesc.Output())
+	if err := conn.Invoke(ctx, fullMethod, req, resp); err != nil {
+		return err
+	}
+	return printMessage(resp)
+}
+
+// callServerStream drives a server-streaming RPC such as TailLogs, printing
+// one JSON line per message received until the server closes the stream.
+func callServerStream(ctx context.Context, conn *grpc.ClientConn, fullMethod string, req proto.Message, out protoreflect.MessageDescriptor) error {
+	desc := &grpc.StreamDesc{StreamName: fullMethod, ServerStreams: true}
+	stream, err := conn.NewStream(ctx, desc, fullMethod)
+	if err != nil {
+		return err
+	}
+	if err := stream.SendMsg(req); err != nil {
+		return err
+	}
+	if err := stream.CloseSend(); err != nil {
+		return err
+	}
+
+	for {
+		resp := dynamicpb.NewMessage(out)
+		if err := stream.RecvMsg(resp); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if err := printMessage(resp); err != nil {
+			return err
+		}
+	}
+}
+
+func printMessage(msg proto.Message) error {
+	b, err := protojson.MarshalOptions{Multiline: false}.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(b))
+	return nil
+}
+
+func splitMethod(method string) (service, name string, err error) {
+	i := strings.LastIndex(method, "/")
+	if i < 0 {
+		return "", "", fmt.Errorf("malformed method %q, expected Service/Method", method)
+	}
+	return method[:i], method[i+1:], nil
+}
+
+// resolveFiles fetches the file descriptors describing svc from the
+// server's reflection service and assembles them into a protoregistry.Files
+// so request/response message types can be built dynamically, without any
+// generated .pb.go code shipped alongside the CLI.
+func resolveFiles(ctx context.Context, conn *grpc.ClientConn, svc string) (*protoregistry.Files, error) {
+	client := grpc_reflection_v1alpha.NewServerReflectionClient(conn)
+	stream, err := client.ServerReflectionInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.CloseSend()
+
+	if err := stream.Send(&grpc_reflection_v1alpha.ServerReflectionRequest{
+		MessageRequest: &grpc_reflection_v1alpha.ServerReflectionRequest_FileContainingSymbol{
+			FileContainingSymbol: svc,
+		},
+	}); err != nil {
+		return nil, err
+	}
+
+	resp, err := stream.Recv()
+	if err != nil {
+		return nil, err
+	}
+
+	fdResp := resp.GetFileDescriptorResponse()
+	if fdResp == nil {
+		return nil, fmt.Errorf("unexpected reflection response for %s", svc)
+	}
+
+	raw := make(map[string]*descriptorpb.FileDescriptorProto, len(fdResp.FileDescriptorProto))
+	order := make([]string, 0, len(fdResp.FileDescriptorProto))
+
+	for _, b := range fdResp.FileDescriptorProto {
+		fd := &descriptorpb.FileDescriptorProto{}
+		if err := proto.Unmarshal(b, fd); err != nil {
+			return nil, err
+		}
+		raw[fd.GetName()] = fd
+		order = append(order, fd.GetName())
+	}
+
+	files := new(protoregistry.Files)
+	registered := make(map[string]bool, len(raw))
+
+	var register func(name string) error
+	register = func(name string) error {
+		if registered[name] {
+			return nil
+		}
+		fd, ok := raw[name]
+		if !ok {
+			return nil
+		}
+		for _, dep := range fd.GetDependency() {
+			if err := register(dep); err != nil {
+				return err
+			}
+		}
+		fileDesc, err := protodesc.NewFile(fd, files)
+		if err != nil {
+			return err
+		}
+		if err := files.RegisterFile(fileDesc); err != nil {
+			return err
+		}
+		registered[name] = true
+		return nil
+	}
+
+	for _, name := range order {
+		if err := register(name); err != nil {
+			return nil, err
+		}
+	}
+
+	return files, nil
+}
+
+func findService(files *protoregistry.Files, name string) (protoreflect.ServiceDescriptor, error) {
+	desc, err := files.FindDescriptorByName(protoreflect.FullName(name))
+	if err != nil {
+		return nil, fmt.Errorf("service %s: %w", name, err)
+	}
+	svcDesc, ok := desc.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a service", name)
+	}
+	return svcDesc, nil
+}
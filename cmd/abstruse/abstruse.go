@@ -1,9 +1,11 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"log"
 	"os"
+	"strings"
 
 	"github.com/bleenco/abstruse/server"
 )
@@ -16,27 +18,92 @@ var (
 	grpcPort     = flag.Int("grpc-port", 3330, "gRPC server port")
 	gRPCCertFile = flag.String("grpc-cert", "", "path to cert file")
 	gRPCKeyFile  = flag.String("grpc-certkey", "", "path to cert key file")
+	gRPCReflect  = flag.Bool("grpc-reflection", false, "register gRPC server reflection")
+
+	listenAddr = flag.String("listen", "", "multiplex HTTP, HTTPS and gRPC on a single TLS listener at this address instead of opening separate listeners (requires -grpc-embedded)")
+	grpcEmbed  = flag.Bool("grpc-embedded", false, "serve gRPC traffic off the -listen muxed listener instead of its own port")
+
+	httpSocket  = flag.String("http-socket", "", "additionally serve HTTP over a unix domain socket at this path")
+	httpsSocket = flag.String("https-socket", "", "additionally serve HTTPS over a unix domain socket at this path")
+	grpcSocket  = flag.String("grpc-socket", "", "serve gRPC over a unix domain socket at this path instead of a TCP port")
+	socketMode  = flag.Uint("socket-mode", 0660, "file mode applied to unix domain sockets")
+	socketOwner = flag.String("socket-owner", "", "chown unix domain sockets to this user")
+	socketGroup = flag.String("socket-group", "", "chown unix domain sockets to this group")
+
+	grpcClientCA          = flag.String("grpc-client-ca", "", "path to CA bundle used to verify worker client certificates (enables mTLS)")
+	grpcRequireClientCert = flag.Bool("grpc-require-client-cert", false, "reject gRPC connections that don't present a certificate signed by -grpc-client-ca")
+
+	adminAddr = flag.String("admin", "", "serve /metrics, /debug/pprof and /status on a dedicated listener at this address instead of the public router")
+
+	acmeEnabled       = flag.Bool("acme", false, "issue and renew the HTTPS certificate automatically via ACME (e.g. Let's Encrypt) instead of -cert/-certkey")
+	acmeHostnames     = flag.String("acme-hostnames", "", "comma-separated hostnames the ACME certificate is issued for")
+	acmeEmail         = flag.String("acme-email", "", "contact email passed to the ACME provider")
+	acmeCacheDir      = flag.String("acme-cache-dir", "", "directory issued ACME certificates are persisted to (defaults to <dir>/acme)")
+	acmeChallengeType = flag.String("acme-challenge-type", "http-01", "ACME challenge type: http-01 or tls-alpn-01")
 )
 
 func main() {
 	flag.Parse()
 
+	// GatewayHandlers (the grpc-gateway REST/JSON bindings under /api/v2/)
+	// is left unset here: it takes generated Register{Service}Handler
+	// functions that don't exist in this tree, so wiring it up is
+	// library-only for now. Callers embedding server.Abstruse directly can
+	// populate AbstruseConfig.GatewayHandlers themselves.
 	config := &server.AbstruseConfig{
-		HTTPAddress:  *httpAddr,
-		HTTPSAddress: *httpsAddr,
-		CertFile:     *certFile,
-		KeyFile:      *keyFile,
+		HTTPAddress:   *httpAddr,
+		HTTPSAddress:  *httpsAddr,
+		CertFile:      *certFile,
+		KeyFile:       *keyFile,
+		ListenAddress: *listenAddr,
+		HTTPSocket:    *httpSocket,
+		HTTPSSocket:   *httpsSocket,
+		SocketMode:    os.FileMode(*socketMode),
+		SocketOwner:   *socketOwner,
+		SocketGroup:   *socketGroup,
+		AdminAddress:  *adminAddr,
+		ACME: &server.ACMEConfig{
+			Enabled:       *acmeEnabled,
+			Hostnames:     splitCommaList(*acmeHostnames),
+			Email:         *acmeEmail,
+			CacheDir:      *acmeCacheDir,
+			ChallengeType: *acmeChallengeType,
+		},
 		GRPCConfig: &server.GRPCServerConfig{
-			Port:    *grpcPort,
-			Cert:    *gRPCCertFile,
-			CertKey: *gRPCKeyFile,
+			Port:              *grpcPort,
+			Cert:              *gRPCCertFile,
+			CertKey:           *gRPCKeyFile,
+			Reflection:        *gRPCReflect,
+			Embedded:          *grpcEmbed,
+			GRPCSocket:        *grpcSocket,
+			SocketMode:        os.FileMode(*socketMode),
+			SocketOwner:       *socketOwner,
+			SocketGroup:       *socketGroup,
+			ClientCAFile:      *grpcClientCA,
+			RequireClientCert: *grpcRequireClientCert,
 		},
 	}
 	abstruse, _ := server.NewAbstruse(config)
 
-	if err := abstruse.Run(); err != nil {
+	if err := abstruse.Run(context.Background()); err != nil {
 		log.Fatal(err)
 	}
 
 	os.Exit(0)
 }
+
+// splitCommaList splits a comma-separated flag value into its components,
+// dropping empty entries so an unset flag yields a nil slice.
+func splitCommaList(s string) []string {
+	if s == "" {
+		return nil
+	}
+
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
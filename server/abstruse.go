@@ -1,12 +1,20 @@
 package server
 
 import (
-	"log"
+	"context"
+	"crypto/tls"
+	"fmt"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/bleenco/abstruse/db"
-	humanize "github.com/dustin/go-humanize"
-	"github.com/felixge/httpsnoop"
+	"github.com/soheilhy/cmux"
+	"golang.org/x/crypto/acme/autocert"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc"
 
 	"github.com/bleenco/abstruse/config"
 	"github.com/bleenco/abstruse/fs"
@@ -15,27 +23,75 @@ import (
 	"golang.org/x/net/http2"
 )
 
+// defaultShutdownTimeout is used when AbstruseConfig.ShutdownTimeout is
+// left unset.
+const defaultShutdownTimeout = 30 * time.Second
+
 // AbstruseConfig defines configuration of the main master server.
 type AbstruseConfig struct {
 	HTTPAddress  string
 	HTTPSAddress string
 	CertFile     string
 	KeyFile      string
-	GRPCConfig    *GRPCServerConfig
+	GRPCConfig   *GRPCServerConfig
 	Dir          string
 	Debug        bool
+
+	// ListenAddress, when set, multiplexes HTTP, HTTPS and gRPC traffic over
+	// a single TLS listener via cmux instead of opening separate listeners
+	// for each. GRPCConfig.Embedded must also be set to true in this mode.
+	ListenAddress string
+
+	// GatewayHandlers registers the grpc-gateway REST/JSON bindings mounted
+	// on the router under /api/v2/. Leave empty to disable the gateway.
+	// Requires GRPCConfig.Embedded, since the gateway reaches the gRPC
+	// server over a plaintext in-memory connection.
+	GatewayHandlers []GatewayRegisterFunc
+
+	// HTTPSocket and HTTPSSocket, when set, additionally serve HTTP and
+	// HTTPS over a unix domain socket at the given path, letting operators
+	// front Abstruse with a local reverse proxy without burning TCP ports.
+	HTTPSocket  string
+	HTTPSSocket string
+
+	// SocketMode, SocketOwner and SocketGroup control the permissions and
+	// ownership applied to HTTPSocket/HTTPSSocket. SocketMode defaults to
+	// defaultSocketMode (0660) when left zero; SocketOwner/SocketGroup
+	// leave ownership unchanged when left empty.
+	SocketMode  os.FileMode
+	SocketOwner string
+	SocketGroup string
+
+	// ShutdownTimeout bounds how long Run waits for in-flight HTTP requests
+	// and gRPC streams to finish on SIGINT/SIGTERM before forcing them
+	// closed. Defaults to defaultShutdownTimeout.
+	ShutdownTimeout time.Duration
+
+	// AdminAddress, when set, serves /metrics, /debug/pprof and the status
+	// page on a dedicated listener instead of folding them into the public
+	// router, so ops can scrape metrics without exposing them on the
+	// public port.
+	AdminAddress string
+
+	// ACME, when set and enabled, issues and renews HTTPS certificates
+	// automatically via an ACME provider such as Let's Encrypt instead of
+	// requiring a static CertFile/KeyFile pair.
+	ACME *ACMEConfig
 }
 
 // Abstruse represents main master server.
 type Abstruse struct {
-	config *AbstruseConfig
-	router *Router
-	server *http.Server
-	logger *logger.Logger
-	grpcserver *GRPCServer
-	dir    string
-
-	running chan error
+	config          *AbstruseConfig
+	router          *Router
+	server          *http.Server
+	httpServer      *http.Server
+	httpsSockServer *http.Server
+	adminServer     *http.Server
+	logger          *logger.Logger
+	grpcserver      *GRPCServer
+	dir             string
+	cert            tls.Certificate
+	mux             cmux.CMux
 }
 
 // NewAbstruse creates a new main master server instance.
@@ -71,77 +127,242 @@ func NewAbstruse(c *AbstruseConfig) (*Abstruse, error) {
 	gRPCServer.logger = log
 
 	return &Abstruse{
-		config:  c,
-		router:  NewRouter(),
-		server:  &http.Server{},
-		grpcserver: gRPCServer,
-		logger:  log,
-		dir:     dir,
-		running: make(chan error, 1),
+		config:          c,
+		router:          NewRouter(),
+		server:          &http.Server{},
+		httpServer:      &http.Server{},
+		httpsSockServer: &http.Server{},
+		grpcserver:      gRPCServer,
+		logger:          log,
+		dir:             dir,
 	}, nil
 }
 
-// Run starts the main server instance.
-func (a *Abstruse) Run() error {
-	if err := a.init(); err != nil {
+// Run starts the main server instance and blocks until ctx is canceled (or
+// a SIGINT/SIGTERM is received) and every server has shut down, or until
+// one of the servers fails to start.
+func (a *Abstruse) Run(ctx context.Context) error {
+	ctx, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	g, gctx := errgroup.WithContext(ctx)
+
+	if err := a.init(gctx, g); err != nil {
 		return err
 	}
 
-	handler := http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
-		m := httpsnoop.CaptureMetrics(a.router, res, req)
-		a.logger.Infof(
-			"%s %s (code=%d dt=%s written=%s remote=%s)",
-			req.Method,
-			req.URL,
-			m.Code,
-			m.Duration,
-			humanize.Bytes(uint64(m.Written)),
-			req.RemoteAddr,
-		)
-	})
+	a.startAdmin(g)
 
-	if a.config.HTTPAddress != "" {
-		go func() {
-			a.logger.Infof("http listening on %s", a.config.HTTPAddress)
-			log.Fatal(http.ListenAndServe(a.config.HTTPAddress, handler))
-		}()
+	handler := chainMiddleware(a.router, a.loggingMiddleware, metricsMiddleware)
+
+	var acmeManager *autocert.Manager
+	if a.config.ACME != nil && a.config.ACME.Enabled {
+		acmeManager = a.newACMEManager()
 	}
 
-	if a.config.HTTPSAddress != "" && a.config.CertFile != "" && a.config.KeyFile != "" {
-		go func() {
+	httpHandler := handler
+	if acmeManager != nil && a.config.ACME.ChallengeType != challengeTLSALPN01 {
+		httpHandler = acmeManager.HTTPHandler(handler)
+	}
+
+	if a.config.ListenAddress != "" && a.config.GRPCConfig.Embedded {
+		// The muxed listener only ever terminates TLS; it never stands up a
+		// plaintext HTTP listener carrying httpHandler, so an http-01
+		// challenge (which ACME validates over plain HTTP on port 80) could
+		// never be served here. tls-alpn-01 completes entirely within the
+		// TLS handshake acmeTLSConfig already wires up, so it works fine.
+		if acmeManager != nil && a.config.ACME.ChallengeType != challengeTLSALPN01 {
+			return fmt.Errorf("server: ACME challenge type %q is not servable on the muxed listener; use %q", a.config.ACME.ChallengeType, challengeTLSALPN01)
+		}
+
+		var tlsConfig *tls.Config
+		if acmeManager != nil {
+			tlsConfig = acmeTLSConfig(acmeManager, a.config.ACME.ChallengeType)
+		} else {
+			cert, err := tls.LoadX509KeyPair(a.config.CertFile, a.config.KeyFile)
+			if err != nil {
+				return err
+			}
+			a.cert = cert
+
+			tlsConfig = &tls.Config{
+				Certificates: []tls.Certificate{a.cert},
+				NextProtos:   []string{http2.NextProtoTLS, "http/1.1"},
+			}
+		}
+
+		if err := a.runMuxed(g, handler, tlsConfig); err != nil {
+			return err
+		}
+	} else {
+		if a.config.HTTPAddress != "" {
+			a.httpServer.Addr = a.config.HTTPAddress
+			a.httpServer.Handler = httpHandler
+			g.Go(func() error {
+				a.logger.Infof("http listening on %s", a.config.HTTPAddress)
+				if err := a.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+					return err
+				}
+				return nil
+			})
+		}
+
+		socketConfig := SocketConfig{Mode: a.config.SocketMode, Owner: a.config.SocketOwner, Group: a.config.SocketGroup}
+
+		if a.config.HTTPSocket != "" {
+			a.httpServer.Handler = httpHandler
+			g.Go(func() error {
+				ln, err := listenUnix(a.config.HTTPSocket, socketConfig)
+				if err != nil {
+					return err
+				}
+
+				a.logger.Infof("http listening on unix socket %s", a.config.HTTPSocket)
+				if err := a.httpServer.Serve(ln); err != nil && err != http.ErrServerClosed {
+					return err
+				}
+				return nil
+			})
+		}
+
+		if a.config.HTTPSAddress != "" && (acmeManager != nil || (a.config.CertFile != "" && a.config.KeyFile != "")) {
 			a.server.Addr = a.config.HTTPSAddress
 			a.server.Handler = handler
 			http2.ConfigureServer(a.server, nil)
 
-			a.logger.Infof("https listening on %s", a.config.HTTPSAddress)
-			log.Fatal(a.server.ListenAndServeTLS(a.config.CertFile, a.config.KeyFile))
-		}()
+			g.Go(func() error {
+				a.logger.Infof("https listening on %s", a.config.HTTPSAddress)
+
+				var err error
+				if acmeManager != nil {
+					a.server.TLSConfig = acmeTLSConfig(acmeManager, a.config.ACME.ChallengeType)
+					err = a.server.ListenAndServeTLS("", "")
+				} else {
+					err = a.server.ListenAndServeTLS(a.config.CertFile, a.config.KeyFile)
+				}
+				if err != nil && err != http.ErrServerClosed {
+					return err
+				}
+				return nil
+			})
+		}
+
+		if a.config.HTTPSSocket != "" && (acmeManager != nil || (a.config.CertFile != "" && a.config.KeyFile != "")) {
+			a.httpsSockServer.Handler = handler
+			http2.ConfigureServer(a.httpsSockServer, nil)
+
+			g.Go(func() error {
+				ln, err := listenUnix(a.config.HTTPSSocket, socketConfig)
+				if err != nil {
+					return err
+				}
+
+				a.logger.Infof("https listening on unix socket %s", a.config.HTTPSSocket)
+
+				if acmeManager != nil {
+					a.httpsSockServer.TLSConfig = acmeTLSConfig(acmeManager, a.config.ACME.ChallengeType)
+					err = a.httpsSockServer.ServeTLS(ln, "", "")
+				} else {
+					err = a.httpsSockServer.ServeTLS(ln, a.config.CertFile, a.config.KeyFile)
+				}
+				if err != nil && err != http.ErrServerClosed {
+					return err
+				}
+				return nil
+			})
+		}
+
+		// Embedded gRPC never gets a listener of its own (NewGRPCServer left it
+		// without grpc.Creds); it is reached through the muxed listener or the
+		// gateway's in-memory pipe only.
+		if !a.config.GRPCConfig.Embedded && ((a.config.GRPCConfig.Cert != "" && a.config.GRPCConfig.CertKey != "") || a.config.GRPCConfig.GRPCSocket != "") {
+			g.Go(func() error {
+				if err := a.grpcserver.Listen(); err != nil && err != grpc.ErrServerStopped {
+					return err
+				}
+				return nil
+			})
+		} else if !a.config.GRPCConfig.Embedded {
+			return errNoGRPCCredentials
+		}
+	}
+
+	g.Go(func() error {
+		<-gctx.Done()
+		return a.shutdown()
+	})
+
+	return g.Wait()
+}
+
+// Close requests that the main server instance shut down gracefully,
+// waiting up to ShutdownTimeout for in-flight work to finish.
+func (a *Abstruse) Close() error {
+	return a.shutdown()
+}
+
+// shutdown gracefully stops the HTTP, HTTPS and gRPC servers, allowing
+// running builds streaming over gRPC to reach a checkpoint before falling
+// back to an abrupt stop once ShutdownTimeout elapses.
+func (a *Abstruse) shutdown() error {
+	a.logger.Infof("shutting down...")
+
+	timeout := a.config.ShutdownTimeout
+	if timeout == 0 {
+		timeout = defaultShutdownTimeout
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var errs []error
+
+	if err := a.httpServer.Shutdown(shutdownCtx); err != nil {
+		errs = append(errs, err)
 	}
 
-	if a.config.GRPCConfig.Cert != "" && a.config.GRPCConfig.CertKey != "" {
+	if err := a.server.Shutdown(shutdownCtx); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := a.httpsSockServer.Shutdown(shutdownCtx); err != nil {
+		errs = append(errs, err)
+	}
+
+	if a.adminServer != nil {
+		if err := a.adminServer.Shutdown(shutdownCtx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if a.grpcserver != nil && a.grpcserver.Server != nil {
+		stopped := make(chan struct{})
 		go func() {
-			if err := a.grpcserver.Listen(); err != nil {
-				log.Fatal(err)
-			}
+			a.grpcserver.Server.GracefulStop()
+			close(stopped)
 		}()
-	} else {
-		log.Fatal("gRPC server cannot work without certificate and key path specified. exiting...")
+
+		select {
+		case <-stopped:
+		case <-shutdownCtx.Done():
+			a.grpcserver.Server.Stop()
+		}
 	}
 
-	return a.wait()
-}
+	if a.mux != nil {
+		a.mux.Close()
+	}
 
-// Close gracefully stops main server.
-func (a *Abstruse) Close() {
-	a.logger.Infof("closing down http server...")
-	if err := a.server.Close(); err != nil {
-		a.running <- err
+	removeStaleSocket(a.config.HTTPSocket)
+	removeStaleSocket(a.config.HTTPSSocket)
+	if a.config.GRPCConfig != nil {
+		removeStaleSocket(a.config.GRPCConfig.GRPCSocket)
 	}
 
-	a.running <- nil
+	return aggregateErrors(errs)
 }
 
-func (a *Abstruse) init() error {
+func (a *Abstruse) init(ctx context.Context, g *errgroup.Group) error {
 	if !fs.Exists(a.dir) {
 		if err := fs.MakeDir(a.dir); err != nil {
 			return err
@@ -170,9 +391,9 @@ func (a *Abstruse) init() error {
 		return err
 	}
 
-	return nil
-}
+	if err := a.initGateway(ctx, g, a.config.GatewayHandlers); err != nil {
+		return err
+	}
 
-func (a *Abstruse) wait() error {
-	return <-a.running
+	return nil
 }
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"crypto/tls"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+	"golang.org/x/net/http2"
+)
+
+// ACME challenge types supported by ACMEConfig.ChallengeType.
+const (
+	challengeHTTP01    = "http-01"
+	challengeTLSALPN01 = "tls-alpn-01"
+)
+
+// ACMEConfig configures automatic certificate issuance and renewal via an
+// ACME provider such as Let's Encrypt, as an alternative to supplying a
+// static CertFile/KeyFile pair.
+type ACMEConfig struct {
+	Enabled   bool
+	Hostnames []string
+	Email     string
+
+	// CacheDir is where issued certificates are persisted so restarts
+	// don't re-issue them. Defaults to "<dir>/acme".
+	CacheDir string
+
+	// ChallengeType selects how the ACME provider validates domain
+	// ownership: "http-01" (default) or "tls-alpn-01".
+	ChallengeType string
+}
+
+// newACMEManager builds an autocert.Manager from a.config.ACME, persisting
+// issued certificates under CacheDir (or dir/acme if unset).
+func (a *Abstruse) newACMEManager() *autocert.Manager {
+	c := a.config.ACME
+
+	cacheDir := c.CacheDir
+	if cacheDir == "" {
+		cacheDir = a.dir + "/acme"
+	}
+
+	return &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(c.Hostnames...),
+		Cache:      autocert.DirCache(cacheDir),
+		Email:      c.Email,
+	}
+}
+
+// acmeTLSConfig builds the tls.Config used by the HTTPS and muxed listeners
+// when ACME is enabled, sourcing certificates from m instead of a static
+// CertFile/KeyFile pair. For tls-alpn-01, "acme-tls/1" is advertised so the
+// manager can complete the challenge directly on the TLS handshake.
+func acmeTLSConfig(m *autocert.Manager, challengeType string) *tls.Config {
+	tlsConfig := &tls.Config{
+		GetCertificate: m.GetCertificate,
+		NextProtos:     []string{http2.NextProtoTLS, "http/1.1"},
+	}
+
+	if challengeType == challengeTLSALPN01 {
+		tlsConfig.NextProtos = append([]string{acme.ALPNProto}, tlsConfig.NextProtos...)
+	}
+
+	return tlsConfig
+}
@@ -0,0 +1,105 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/bleenco/abstruse/db"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// buildClientAuthTLSConfig loads the CA used to verify worker client
+// certificates and returns base configured for mutual TLS. base is
+// returned unchanged when no client CA is configured.
+func buildClientAuthTLSConfig(base *tls.Config, c *GRPCServerConfig) (*tls.Config, error) {
+	if c.ClientCAFile == "" {
+		return base, nil
+	}
+
+	pem, err := ioutil.ReadFile(c.ClientCAFile)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("grpc: failed to parse client CA file %s", c.ClientCAFile)
+	}
+
+	cfg := base.Clone()
+	cfg.ClientCAs = pool
+	if c.RequireClientCert {
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	} else {
+		cfg.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+
+	return cfg, nil
+}
+
+// workerCommonName extracts the CommonName of the client certificate
+// presented by the peer on ctx, which Abstruse uses as the worker identity.
+func workerCommonName(ctx context.Context) (string, error) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return "", status.Error(codes.Unauthenticated, "missing peer info")
+	}
+
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(tlsInfo.State.PeerCertificates) == 0 {
+		return "", status.Error(codes.Unauthenticated, "missing client certificate")
+	}
+
+	return tlsInfo.State.PeerCertificates[0].Subject.CommonName, nil
+}
+
+// workerEnrolled looks up whether cn is enrolled as a worker. It is
+// indirected through a var, rather than calling db.WorkerEnrolled directly,
+// so tests can substitute it without a real database connection.
+var workerEnrolled = db.WorkerEnrolled
+
+// authenticateWorker rejects the RPC on ctx unless its client certificate
+// CommonName is enrolled as a worker in the database.
+func authenticateWorker(ctx context.Context) error {
+	cn, err := workerCommonName(ctx)
+	if err != nil {
+		return err
+	}
+
+	enrolled, err := workerEnrolled(cn)
+	if err != nil {
+		return status.Errorf(codes.Internal, "worker lookup failed: %v", err)
+	}
+	if !enrolled {
+		return status.Errorf(codes.PermissionDenied, "worker %s is not enrolled", cn)
+	}
+
+	return nil
+}
+
+// workerAuthUnaryInterceptor rejects unary RPCs whose client certificate
+// CommonName is not enrolled as a worker in the database.
+func workerAuthUnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := authenticateWorker(ctx); err != nil {
+		return nil, err
+	}
+
+	return handler(ctx, req)
+}
+
+// workerAuthStreamInterceptor rejects streaming RPCs whose client
+// certificate CommonName is not enrolled as a worker in the database.
+func workerAuthStreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := authenticateWorker(ss.Context()); err != nil {
+		return err
+	}
+
+	return handler(srv, ss)
+}
@@ -0,0 +1,132 @@
+package server
+
+import (
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func contextWithCert(cn string) context.Context {
+	state := credentials.TLSInfo{}
+	if cn != "" {
+		state.State.PeerCertificates = []*x509.Certificate{
+			{Subject: pkix.Name{CommonName: cn}},
+		}
+	}
+
+	return peer.NewContext(context.Background(), &peer.Peer{AuthInfo: state})
+}
+
+func TestWorkerCommonName(t *testing.T) {
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		want    string
+		wantErr codes.Code
+	}{
+		{
+			name:    "no peer info",
+			ctx:     context.Background(),
+			wantErr: codes.Unauthenticated,
+		},
+		{
+			name:    "non-TLS peer",
+			ctx:     peer.NewContext(context.Background(), &peer.Peer{AuthInfo: nil}),
+			wantErr: codes.Unauthenticated,
+		},
+		{
+			name:    "no client certificate",
+			ctx:     contextWithCert(""),
+			wantErr: codes.Unauthenticated,
+		},
+		{
+			name: "client certificate present",
+			ctx:  contextWithCert("worker-1"),
+			want: "worker-1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cn, err := workerCommonName(tt.ctx)
+
+			if tt.wantErr != codes.OK {
+				if status.Code(err) != tt.wantErr {
+					t.Fatalf("workerCommonName() err = %v, want code %v", err, tt.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("workerCommonName() unexpected error: %v", err)
+			}
+			if cn != tt.want {
+				t.Fatalf("workerCommonName() = %q, want %q", cn, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthenticateWorker(t *testing.T) {
+	origWorkerEnrolled := workerEnrolled
+	defer func() { workerEnrolled = origWorkerEnrolled }()
+
+	tests := []struct {
+		name      string
+		ctx       context.Context
+		enrolled  bool
+		lookupErr error
+		wantErr   codes.Code
+	}{
+		{
+			name:    "no peer info",
+			ctx:     context.Background(),
+			wantErr: codes.Unauthenticated,
+		},
+		{
+			name:     "enrolled worker",
+			ctx:      contextWithCert("worker-1"),
+			enrolled: true,
+		},
+		{
+			name:     "not enrolled",
+			ctx:      contextWithCert("worker-1"),
+			enrolled: false,
+			wantErr:  codes.PermissionDenied,
+		},
+		{
+			name:      "lookup failure",
+			ctx:       contextWithCert("worker-1"),
+			lookupErr: errors.New("db unavailable"),
+			wantErr:   codes.Internal,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workerEnrolled = func(cn string) (bool, error) {
+				return tt.enrolled, tt.lookupErr
+			}
+
+			err := authenticateWorker(tt.ctx)
+
+			if tt.wantErr != codes.OK {
+				if status.Code(err) != tt.wantErr {
+					t.Fatalf("authenticateWorker() err = %v, want code %v", err, tt.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("authenticateWorker() unexpected error: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,22 @@
+package server
+
+import "fmt"
+
+// errNoGRPCCredentials is returned by Run when the gRPC server is
+// configured to listen on its own TCP port but no certificate/key pair was
+// provided for it. A unix socket (GRPCSocket) is exempt, since it relies on
+// filesystem permissions rather than TLS for access control.
+var errNoGRPCCredentials = fmt.Errorf("gRPC server cannot work without certificate and key path specified")
+
+// aggregateErrors combines zero or more shutdown errors into a single
+// error, so the caller sees every failure instead of just the first.
+func aggregateErrors(errs []error) error {
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return errs[0]
+	default:
+		return fmt.Errorf("shutdown errors: %v", errs)
+	}
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const gatewayBufSize = 1024 * 1024
+
+// GatewayRegisterFunc registers a single proto service's grpc-gateway
+// handler (the generated Register{Service}HandlerFromEndpoint /
+// Register{Service}Handler functions) against mux, using conn to reach the
+// in-process gRPC server.
+type GatewayRegisterFunc func(ctx context.Context, mux *runtime.ServeMux, conn *grpc.ClientConn) error
+
+// initGateway dials the in-process gRPC server over an in-memory bufconn
+// listener and mounts a REST/JSON runtime.ServeMux under /api/v2/ on the
+// router, so API consumers get a REST surface without duplicating business
+// logic in Go HTTP handlers.
+func (a *Abstruse) initGateway(ctx context.Context, g *errgroup.Group, handlers []GatewayRegisterFunc) error {
+	if len(handlers) == 0 {
+		return nil
+	}
+
+	// The gateway dials a.grpcserver.Server over a plaintext bufconn pipe.
+	// That's only safe when the server itself never installs grpc.Creds,
+	// which NewGRPCServer guarantees when Embedded is true; outside embedded
+	// mode a Cert/CertKey pair gets grpc.Creds(credentials.NewTLS(...))
+	// installed and the insecure bufconn dial would never complete the TLS
+	// handshake the server expects.
+	if !a.config.GRPCConfig.Embedded {
+		return fmt.Errorf("server: GatewayHandlers requires GRPCConfig.Embedded, since the gateway dials the gRPC server over a plaintext in-memory connection")
+	}
+
+	lis := bufconn.Listen(gatewayBufSize)
+	g.Go(func() error {
+		if err := a.grpcserver.Server.Serve(lis); err != nil && err != grpc.ErrServerStopped {
+			return err
+		}
+		return nil
+	})
+
+	conn, err := grpc.DialContext(
+		ctx,
+		"bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		return err
+	}
+
+	mux := runtime.NewServeMux()
+	for _, register := range handlers {
+		if err := register(ctx, mux, conn); err != nil {
+			return err
+		}
+	}
+
+	a.router.Handle("/api/v2/", http.StripPrefix("/api/v2", mux))
+	a.logger.Infof("grpc-gateway mounted on /api/v2/")
+
+	return nil
+}
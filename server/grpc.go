@@ -0,0 +1,159 @@
+package server
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/bleenco/abstruse/logger"
+	grpcprometheus "github.com/grpc-ecosystem/go-grpc-prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/reflection"
+)
+
+// GRPCServerConfig defines configuration of the gRPC API server.
+type GRPCServerConfig struct {
+	Port    int
+	Cert    string
+	CertKey string
+
+	// Embedded, when true, disables the dedicated gRPC listener started by
+	// Listen and instead lets Abstruse.Run serve gRPC traffic off the shared
+	// muxed listener alongside HTTP and HTTPS.
+	Embedded bool
+
+	// GRPCSocket, when set, makes Listen open a unix domain socket at this
+	// path instead of a TCP port.
+	GRPCSocket string
+
+	// SocketMode, SocketOwner and SocketGroup control the permissions and
+	// ownership applied to GRPCSocket. SocketMode defaults to
+	// defaultSocketMode (0660) when left zero; SocketOwner/SocketGroup
+	// leave ownership unchanged when left empty.
+	SocketMode  os.FileMode
+	SocketOwner string
+	SocketGroup string
+
+	// ClientCAFile is the PEM-encoded CA bundle used to verify worker
+	// client certificates. When set, workers authenticate via mTLS instead
+	// of relying on network-level trust.
+	ClientCAFile string
+
+	// RequireClientCert, when true, rejects connections that do not
+	// present a certificate signed by ClientCAFile.
+	RequireClientCert bool
+
+	// Reflection, when true, registers the gRPC server reflection service
+	// so tools such as grpcurl or abstruse-cli can introspect the API
+	// without shipping proto files.
+	Reflection bool
+}
+
+// GRPCServer represents the gRPC API server.
+type GRPCServer struct {
+	config *GRPCServerConfig
+	logger *logger.Logger
+
+	// Server is the underlying gRPC server, exported so it can be driven
+	// directly by a connection multiplexer when running in embedded mode.
+	Server *grpc.Server
+}
+
+// NewGRPCServer creates a new gRPC server instance.
+func NewGRPCServer(c *GRPCServerConfig, log *logger.Logger) (*GRPCServer, error) {
+	// Outside embedded mode, the worker auth interceptors rely on grpc.Creds
+	// having populated peer.FromContext with the client's TLS certificate.
+	// Without Cert/CertKey the server would run in plaintext and every RPC
+	// would fail with a cryptic "missing client certificate" instead of a
+	// clear misconfiguration error.
+	if c.ClientCAFile != "" && !c.Embedded && (c.Cert == "" || c.CertKey == "") {
+		return nil, fmt.Errorf("grpc: ClientCAFile requires Cert and CertKey to be set")
+	}
+
+	// In embedded mode grpc.Creds is never installed (the TLS handshake, if
+	// any, already happened on the muxed listener before the connection
+	// reaches grpc.Server), so the worker auth interceptors would never find
+	// a client certificate in peer.FromContext and every RPC would be
+	// rejected as unauthenticated. Reject the combination up front instead
+	// of failing every call at runtime.
+	if c.ClientCAFile != "" && c.Embedded {
+		return nil, fmt.Errorf("grpc: ClientCAFile is not supported with Embedded, since the muxed listener does not surface peer certificates to the gRPC server")
+	}
+
+	var opts []grpc.ServerOption
+
+	// In embedded mode the gRPC server is handed already TLS-terminated
+	// connections off the muxed listener (see runMuxed), so installing
+	// grpc.Creds here would attempt a second TLS handshake on top of a
+	// decrypted connection and hang.
+	if !c.Embedded && c.Cert != "" && c.CertKey != "" {
+		cert, err := tls.LoadX509KeyPair(c.Cert, c.CertKey)
+		if err != nil {
+			return nil, err
+		}
+
+		tlsConfig, err := buildClientAuthTLSConfig(&tls.Config{Certificates: []tls.Certificate{cert}}, c)
+		if err != nil {
+			return nil, err
+		}
+
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+
+	unaryInterceptors := []grpc.UnaryServerInterceptor{grpcprometheus.UnaryServerInterceptor}
+	streamInterceptors := []grpc.StreamServerInterceptor{grpcprometheus.StreamServerInterceptor}
+
+	if c.ClientCAFile != "" {
+		unaryInterceptors = append(unaryInterceptors, workerAuthUnaryInterceptor)
+		streamInterceptors = append(streamInterceptors, workerAuthStreamInterceptor)
+	}
+
+	opts = append(opts,
+		grpc.ChainUnaryInterceptor(unaryInterceptors...),
+		grpc.ChainStreamInterceptor(streamInterceptors...),
+	)
+
+	s := grpc.NewServer(opts...)
+	grpcprometheus.Register(s)
+
+	if c.Reflection {
+		reflection.Register(s)
+	}
+
+	return &GRPCServer{
+		config: c,
+		Server: s,
+	}, nil
+}
+
+// Listen starts the gRPC server on its own dedicated port. It is used when
+// the server is not configured to run embedded on a shared listener.
+func (s *GRPCServer) Listen() error {
+	var (
+		ln  net.Listener
+		err error
+	)
+
+	if s.config.GRPCSocket != "" {
+		ln, err = listenUnix(s.config.GRPCSocket, SocketConfig{
+			Mode:  s.config.SocketMode,
+			Owner: s.config.SocketOwner,
+			Group: s.config.SocketGroup,
+		})
+		if s.logger != nil && err == nil {
+			s.logger.Infof("gRPC server listening on unix socket %s", s.config.GRPCSocket)
+		}
+	} else {
+		ln, err = net.Listen("tcp", fmt.Sprintf(":%d", s.config.Port))
+		if s.logger != nil && err == nil {
+			s.logger.Infof("gRPC server listening on :%d", s.config.Port)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	return s.Server.Serve(ln)
+}
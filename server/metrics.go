@@ -0,0 +1,124 @@
+package server
+
+import (
+	"net/http"
+	"net/http/pprof"
+	"strconv"
+
+	humanize "github.com/dustin/go-humanize"
+	"github.com/felixge/httpsnoop"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"golang.org/x/sync/errgroup"
+)
+
+// httpRequestDuration records HTTP request latency observed by the
+// middleware chain. It is labeled by the matched route pattern rather than
+// the raw request path, so parameterized routes don't each mint their own
+// time series.
+var httpRequestDuration = prometheus.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Name: "abstruse_http_request_duration_seconds",
+		Help: "Latency of HTTP requests served by the master server router.",
+	},
+	[]string{"method", "path", "status"},
+)
+
+func init() {
+	prometheus.MustRegister(httpRequestDuration)
+}
+
+// Middleware wraps an http.Handler to add cross-cutting behavior, such as
+// logging or metrics collection, without the router needing to know about
+// it.
+type Middleware func(http.Handler) http.Handler
+
+// chainMiddleware composes mw around h, with mw[0] running outermost.
+func chainMiddleware(h http.Handler, mw ...Middleware) http.Handler {
+	for i := len(mw) - 1; i >= 0; i-- {
+		h = mw[i](h)
+	}
+	return h
+}
+
+// loggingMiddleware logs every request the way Abstruse.Run previously did
+// inline.
+func (a *Abstruse) loggingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		m := httpsnoop.CaptureMetrics(next, res, req)
+		a.logger.Infof(
+			"%s %s (code=%d dt=%s written=%s remote=%s)",
+			req.Method,
+			req.URL,
+			m.Code,
+			m.Duration,
+			humanize.Bytes(uint64(m.Written)),
+			req.RemoteAddr,
+		)
+	})
+}
+
+// metricsMiddleware records request latency into httpRequestDuration,
+// labeled by the route pattern the router matched rather than the raw
+// path, so e.g. future per-ID routes don't blow up cardinality.
+func metricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		req, pattern := withRoutePattern(req)
+		m := httpsnoop.CaptureMetrics(next, res, req)
+
+		label := *pattern
+		if label == "" {
+			label = "unmatched"
+		}
+
+		httpRequestDuration.
+			WithLabelValues(req.Method, label, strconv.Itoa(m.Code)).
+			Observe(m.Duration.Seconds())
+	})
+}
+
+// newAdminMux builds the /metrics, /debug/pprof and status endpoints used
+// to diagnose slow builds and worker-stream backpressure in production. It
+// is mounted either on a dedicated AdminAddress listener or, if that is
+// unset, directly on the public router.
+func newAdminMux() *http.ServeMux {
+	mux := http.NewServeMux()
+
+	mux.Handle("/metrics", promhttp.Handler())
+
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	mux.HandleFunc("/status", func(res http.ResponseWriter, req *http.Request) {
+		res.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		res.Write([]byte("ok\n"))
+	})
+
+	return mux
+}
+
+// startAdmin mounts the admin endpoints on a dedicated listener when
+// AdminAddress is configured, or folds them into the public router
+// otherwise.
+func (a *Abstruse) startAdmin(g *errgroup.Group) {
+	admin := newAdminMux()
+
+	if a.config.AdminAddress == "" {
+		a.router.Handle("/metrics", admin)
+		a.router.Handle("/debug/pprof/", admin)
+		a.router.Handle("/status", admin)
+		return
+	}
+
+	a.adminServer = &http.Server{Addr: a.config.AdminAddress, Handler: admin}
+	g.Go(func() error {
+		a.logger.Infof("admin endpoints listening on %s", a.config.AdminAddress)
+		if err := a.adminServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			return err
+		}
+		return nil
+	})
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+
+	"github.com/soheilhy/cmux"
+	"golang.org/x/net/http2"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc"
+)
+
+// runMuxed opens a single listener on a.config.ListenAddress and demultiplexes
+// gRPC, HTTP/2 and HTTP/1.x traffic onto it, so operators only need to open
+// and forward one port. It is used instead of the separate HTTP/HTTPS/gRPC
+// listeners started by Run when a.config.ListenAddress is set and
+// a.config.GRPCConfig.Embedded is true. tlsConfig
+// supplies the certificates, whether from a.cert or an ACME manager. Each
+// serve loop is tracked on g so Run can wait on and report every failure.
+func (a *Abstruse) runMuxed(g *errgroup.Group, handler http.Handler, tlsConfig *tls.Config) error {
+	ln, err := net.Listen("tcp", a.config.ListenAddress)
+	if err != nil {
+		return err
+	}
+
+	tlsListener := tls.NewListener(ln, tlsConfig)
+
+	m := cmux.New(tlsListener)
+	grpcListener := m.Match(cmux.HTTP2HeaderField("content-type", "application/grpc"))
+	http2Listener := m.Match(cmux.HTTP2())
+	http1Listener := m.Match(cmux.HTTP1Fast())
+	a.mux = m
+
+	a.server.Handler = handler
+	http2.ConfigureServer(a.server, nil)
+
+	g.Go(func() error {
+		if err := a.grpcserver.Server.Serve(grpcListener); err != nil && err != grpc.ErrServerStopped {
+			return err
+		}
+		return nil
+	})
+	g.Go(func() error {
+		if err := a.server.Serve(http2Listener); err != nil && err != http.ErrServerClosed {
+			return err
+		}
+		return nil
+	})
+	g.Go(func() error {
+		if err := http.Serve(http1Listener, handler); err != nil && err != http.ErrServerClosed {
+			return err
+		}
+		return nil
+	})
+	g.Go(func() error {
+		if err := m.Serve(); err != nil && err != cmux.ErrListenerClosed {
+			return err
+		}
+		return nil
+	})
+
+	a.logger.Infof("muxed http/https/grpc listening on %s", a.config.ListenAddress)
+
+	return nil
+}
@@ -0,0 +1,41 @@
+package server
+
+import (
+	"context"
+	"net/http"
+)
+
+// Router is the main HTTP request router of the master server.
+type Router struct {
+	mux *http.ServeMux
+}
+
+// NewRouter creates a new Router instance.
+func NewRouter() *Router {
+	return &Router{mux: http.NewServeMux()}
+}
+
+// routePatternKey is the context key under which withRoutePattern stashes a
+// pointer for ServeHTTP to fill in with the matched pattern.
+type routePatternKey struct{}
+
+// withRoutePattern returns a copy of req carrying a slot that ServeHTTP
+// fills in with the pattern it matched req against, so middleware wrapping
+// the router can label metrics by route template instead of the raw path.
+func withRoutePattern(req *http.Request) (*http.Request, *string) {
+	pattern := new(string)
+	return req.WithContext(context.WithValue(req.Context(), routePatternKey{}, pattern)), pattern
+}
+
+// ServeHTTP implements http.Handler.
+func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if pattern, ok := req.Context().Value(routePatternKey{}).(*string); ok {
+		_, *pattern = r.mux.Handler(req)
+	}
+	r.mux.ServeHTTP(w, req)
+}
+
+// Handle registers handler for the given pattern.
+func (r *Router) Handle(pattern string, handler http.Handler) {
+	r.mux.Handle(pattern, handler)
+}
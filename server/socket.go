@@ -0,0 +1,113 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"strconv"
+)
+
+// defaultSocketMode is applied to unix domain sockets opened by Abstruse
+// when SocketConfig.Mode is left zero, so only the owning user and group
+// can connect to them.
+const defaultSocketMode os.FileMode = 0660
+
+// SocketConfig controls the permissions and ownership applied to a unix
+// domain socket opened by listenUnix.
+type SocketConfig struct {
+	// Mode is the file mode applied to the socket file. Defaults to
+	// defaultSocketMode when zero.
+	Mode os.FileMode
+
+	// Owner and Group, when set, chown the socket file to the named user
+	// and/or group after creation.
+	Owner string
+	Group string
+}
+
+// listenUnix opens a unix domain socket listener at path, removing any
+// stale socket file left behind by a previous, uncleanly terminated
+// process, and applies sc's mode and ownership to the resulting socket
+// file.
+func listenUnix(path string, sc SocketConfig) (net.Listener, error) {
+	if err := removeStaleSocket(path); err != nil {
+		return nil, err
+	}
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	mode := sc.Mode
+	if mode == 0 {
+		mode = defaultSocketMode
+	}
+
+	if err := os.Chmod(path, mode); err != nil {
+		ln.Close()
+		return nil, err
+	}
+
+	if sc.Owner != "" || sc.Group != "" {
+		uid, gid, err := lookupOwner(sc.Owner, sc.Group)
+		if err != nil {
+			ln.Close()
+			return nil, err
+		}
+
+		if err := os.Chown(path, uid, gid); err != nil {
+			ln.Close()
+			return nil, err
+		}
+	}
+
+	return ln, nil
+}
+
+// lookupOwner resolves owner and group to the uid/gid chown expects,
+// leaving either at -1 (meaning "don't change") when not set.
+func lookupOwner(owner, group string) (uid, gid int, err error) {
+	uid, gid = -1, -1
+
+	if owner != "" {
+		u, err := user.Lookup(owner)
+		if err != nil {
+			return -1, -1, fmt.Errorf("socket: lookup owner %q: %w", owner, err)
+		}
+		if uid, err = strconv.Atoi(u.Uid); err != nil {
+			return -1, -1, err
+		}
+	}
+
+	if group != "" {
+		g, err := user.LookupGroup(group)
+		if err != nil {
+			return -1, -1, fmt.Errorf("socket: lookup group %q: %w", group, err)
+		}
+		if gid, err = strconv.Atoi(g.Gid); err != nil {
+			return -1, -1, err
+		}
+	}
+
+	return uid, gid, nil
+}
+
+// removeStaleSocket removes a leftover unix socket file at path, if any, so
+// a subsequent net.Listen("unix", path) does not fail with "address already
+// in use". It is a no-op when path is empty or does not exist.
+func removeStaleSocket(path string) error {
+	if path == "" {
+		return nil
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	return os.Remove(path)
+}